@@ -36,10 +36,17 @@ type ObjectStoreSpec struct {
 type ObjectStoreStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// It is compared against metadata.generation to tell whether the latest spec
+	// has been reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=os
 
 // ObjectStore is the Schema for the objectstores API
 type ObjectStore struct {
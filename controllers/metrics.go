@@ -0,0 +1,72 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// reconcileTotal counts ObjectStore reconciles by result
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "objectstore_reconcile_total",
+		Help: "Total number of ObjectStore reconciliations per result",
+	}, []string{"result"})
+
+	// reconcileErrorsTotal counts ObjectStore reconciles that returned an error
+	// or were requeued because the API server did not answer in time
+	reconcileErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "objectstore_reconcile_errors_total",
+		Help: "Total number of ObjectStore reconciliation errors",
+	})
+
+	// reconcileDuration tracks how long ObjectStore reconciles take
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "objectstore_reconcile_duration_seconds",
+		Help:    "Length of time per ObjectStore reconciliation",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	// Register with the controller-runtime registry so the metrics are served
+	// on the manager's metrics endpoint
+	metrics.Registry.MustRegister(reconcileTotal, reconcileErrorsTotal, reconcileDuration)
+}
+
+// observeReconcile records the outcome and duration of a single reconcile.
+// timedOut reports that the reconcile was requeued after an API server call
+// exceeded its deadline, which is counted as an error even though no error is
+// returned to controller-runtime. Any other reconcile that returns no error is
+// a success, including one requeued by the periodic reconcile interval.
+func observeReconcile(start time.Time, err error, timedOut bool) {
+	reconcileDuration.Observe(time.Since(start).Seconds())
+
+	switch {
+	case err != nil:
+		reconcileErrorsTotal.Inc()
+		reconcileTotal.WithLabelValues("error").Inc()
+	case timedOut:
+		reconcileErrorsTotal.Inc()
+		reconcileTotal.WithLabelValues("timeout").Inc()
+	default:
+		reconcileTotal.WithLabelValues("success").Inc()
+	}
+}
@@ -18,11 +18,17 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	objectv1alpha1 "github.com/leseb/rook-s3-nano/api/v1alpha1"
 )
@@ -31,8 +37,36 @@ import (
 type ObjectStoreReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the maximum number of ObjectStores reconciled
+	// in parallel. Defaults to 1 when unset.
+	MaxConcurrentReconciles int
+
+	// APITimeout bounds each call made to the API server during a reconcile.
+	// Defaults to defaultAPITimeout when unset or not positive.
+	APITimeout time.Duration
+
+	// ReconcileInterval, when set, requeues every successfully reconciled
+	// ObjectStore after this interval so that drift is corrected even when no
+	// event fires. No periodic requeue happens when unset.
+	ReconcileInterval time.Duration
 }
 
+// defaultAPITimeout is used when no APITimeout is configured on the reconciler
+const defaultAPITimeout = 30 * time.Second
+
+// objectStorePredicate filters ObjectStore events so that updates which do not
+// bump metadata.generation, such as our own status writes, do not trigger
+// another reconcile. Annotation changes are let through since some annotations
+// alter how the object store is reconciled.
+var objectStorePredicate = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+
+// debugLogsAnnotation, when set to "true" on an ObjectStore, promotes the
+// verbose reconcile logs of that object store to the default log level so a
+// single noisy object store can be debugged without raising the operator's
+// global verbosity.
+const debugLogsAnnotation = "object.rook-s3-nano/debug-logs"
+
 //+kubebuilder:rbac:groups=object.rook-s3-nano,resources=objectstores,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=object.rook-s3-nano,resources=objectstores/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=object.rook-s3-nano,resources=objectstores/finalizers,verbs=update
@@ -46,17 +80,76 @@ type ObjectStoreReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.2/pkg/reconcile
-func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	var timedOut bool
+	defer func(start time.Time) { observeReconcile(start, err, timedOut) }(time.Now())
+
+	logger := log.FromContext(ctx)
+
+	objectStore := &objectv1alpha1.ObjectStore{}
+	apiCtx, cancel := r.apiContext(ctx)
+	err = r.Get(apiCtx, req.NamespacedName, objectStore)
+	cancel()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Info("timed out fetching object store, requeuing")
+			timedOut = true
+			return ctrl.Result{Requeue: true}, nil
+		}
+		// The object store may have been deleted after the request was queued
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	debugLog := debugLogger(logger, objectStore)
+	debugLog.Info("reconciling object store", "generation", objectStore.Generation)
 
 	// TODO(user): your logic here
 
-	return ctrl.Result{}, nil
+	// Record that this generation of the spec has been reconciled
+	if objectStore.Status.ObservedGeneration != objectStore.Generation {
+		objectStore.Status.ObservedGeneration = objectStore.Generation
+		apiCtx, cancel := r.apiContext(ctx)
+		err := r.Status().Update(apiCtx, objectStore)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				logger.Info("timed out updating object store status, requeuing")
+				timedOut = true
+				return ctrl.Result{Requeue: true}, nil
+			}
+			logger.Error(err, "failed to update object store status")
+			return ctrl.Result{}, err
+		}
+		debugLog.Info("updated object store observed generation", "observedGeneration", objectStore.Status.ObservedGeneration)
+	}
+
+	return ctrl.Result{RequeueAfter: r.ReconcileInterval}, nil
+}
+
+// debugLogger returns the logger used for verbose reconcile messages. It logs at
+// V(1) unless the object store carries the debug logs annotation, in which case
+// the messages are emitted at the default level.
+func debugLogger(logger logr.Logger, objectStore *objectv1alpha1.ObjectStore) logr.Logger {
+	if objectStore.Annotations[debugLogsAnnotation] == "true" {
+		return logger.WithValues("debug", true)
+	}
+	return logger.V(1)
+}
+
+// apiContext derives a context bounded by the reconciler's API timeout so a
+// hung API server call cannot stall a worker indefinitely.
+func (r *ObjectStoreReconciler) apiContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := r.APITimeout
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ObjectStoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&objectv1alpha1.ObjectStore{}).
+		For(&objectv1alpha1.ObjectStore{}, builder.WithPredicates(objectStorePredicate)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
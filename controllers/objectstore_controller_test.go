@@ -0,0 +1,291 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	objectv1alpha1 "github.com/leseb/rook-s3-nano/api/v1alpha1"
+)
+
+// newFakeClient returns a fake client that knows about the object API group
+// and is seeded with the given objects
+func newFakeClient(objs ...client.Object) client.Client {
+	s := runtime.NewScheme()
+	Expect(objectv1alpha1.AddToScheme(s)).To(Succeed())
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+}
+
+// statusCountingClient counts the status writes sent through it
+type statusCountingClient struct {
+	client.Client
+	statusWrites *int
+}
+
+func (c statusCountingClient) Status() client.StatusWriter {
+	return countingStatusWriter{StatusWriter: c.Client.Status(), writes: c.statusWrites}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	writes *int
+}
+
+func (w countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	*w.writes++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w countingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	*w.writes++
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// slowClient is a client whose Get never answers before the caller gives up
+type slowClient struct {
+	client.Client
+}
+
+func (c slowClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// brokenClient is a client whose Get always fails
+type brokenClient struct {
+	client.Client
+}
+
+func (c brokenClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return errors.New("connection refused")
+}
+
+var _ = Describe("ObjectStore controller", func() {
+	Context("When reconciling an ObjectStore", func() {
+		var objectStore *objectv1alpha1.ObjectStore
+		var req ctrl.Request
+
+		BeforeEach(func() {
+			objectStore = &objectv1alpha1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-store", Generation: 2},
+			}
+			req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-store"}}
+		})
+
+		It("Should record the reconciled generation in status", func() {
+			r := &ObjectStoreReconciler{Client: newFakeClient(objectStore)}
+
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &objectv1alpha1.ObjectStore{}
+			Expect(r.Get(context.Background(), req.NamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.ObservedGeneration).To(Equal(int64(2)))
+		})
+
+		It("Should not write status when the generation was already observed", func() {
+			objectStore.Status.ObservedGeneration = 2
+			statusWrites := 0
+			r := &ObjectStoreReconciler{
+				Client: statusCountingClient{Client: newFakeClient(objectStore), statusWrites: &statusWrites},
+			}
+
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statusWrites).To(BeZero())
+		})
+	})
+
+	Context("When the API server does not answer in time", func() {
+		It("Should requeue instead of blocking the worker", func() {
+			r := &ObjectStoreReconciler{
+				Client:     slowClient{Client: fake.NewClientBuilder().Build()},
+				APITimeout: 10 * time.Millisecond,
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-store"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+		})
+
+		It("Should fall back to the default timeout when the configured one is not positive", func() {
+			r := &ObjectStoreReconciler{APITimeout: -time.Second}
+
+			ctx, cancel := r.apiContext(context.Background())
+			defer cancel()
+
+			Expect(ctx.Err()).NotTo(HaveOccurred())
+			deadline, ok := ctx.Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(time.Until(deadline)).To(BeNumerically("~", defaultAPITimeout, time.Second))
+		})
+	})
+
+	Context("When the reconcile fails", func() {
+		It("Should count the error in the reconcile metrics", func() {
+			r := &ObjectStoreReconciler{
+				Client: brokenClient{Client: fake.NewClientBuilder().Build()},
+			}
+			errorsBefore := testutil.ToFloat64(reconcileErrorsTotal)
+			totalBefore := testutil.ToFloat64(reconcileTotal.WithLabelValues("error"))
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-store"},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(testutil.ToFloat64(reconcileErrorsTotal)).To(Equal(errorsBefore + 1))
+			Expect(testutil.ToFloat64(reconcileTotal.WithLabelValues("error"))).To(Equal(totalBefore + 1))
+		})
+
+		It("Should count API server timeouts as errors in the reconcile metrics", func() {
+			r := &ObjectStoreReconciler{
+				Client:     slowClient{Client: fake.NewClientBuilder().Build()},
+				APITimeout: 10 * time.Millisecond,
+			}
+			errorsBefore := testutil.ToFloat64(reconcileErrorsTotal)
+			timeoutsBefore := testutil.ToFloat64(reconcileTotal.WithLabelValues("timeout"))
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-store"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+			Expect(testutil.ToFloat64(reconcileErrorsTotal)).To(Equal(errorsBefore + 1))
+			Expect(testutil.ToFloat64(reconcileTotal.WithLabelValues("timeout"))).To(Equal(timeoutsBefore + 1))
+		})
+	})
+
+	Context("When choosing the debug logger", func() {
+		// logger only emits messages at the default verbosity
+		logger := funcr.New(func(prefix, args string) {}, funcr.Options{Verbosity: 0})
+
+		DescribeTable("Should only enable verbose logs for annotated object stores",
+			func(annotations map[string]string, enabled bool) {
+				objectStore := &objectv1alpha1.ObjectStore{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-store", Annotations: annotations},
+				}
+				Expect(debugLogger(logger, objectStore).Enabled()).To(Equal(enabled))
+			},
+			Entry("annotation set to true", map[string]string{debugLogsAnnotation: "true"}, true),
+			Entry("annotation absent", nil, false),
+			Entry("annotation set to another value", map[string]string{debugLogsAnnotation: "yes"}, false),
+		)
+	})
+
+	Context("When filtering ObjectStore updates", func() {
+		var oldStore *objectv1alpha1.ObjectStore
+
+		BeforeEach(func() {
+			oldStore = &objectv1alpha1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-store", Generation: 1},
+			}
+		})
+
+		It("Should ignore status-only updates", func() {
+			newStore := oldStore.DeepCopy()
+			newStore.Status.ObservedGeneration = 1
+
+			Expect(objectStorePredicate.Update(event.UpdateEvent{ObjectOld: oldStore, ObjectNew: newStore})).To(BeFalse())
+		})
+
+		It("Should reconcile spec updates", func() {
+			newStore := oldStore.DeepCopy()
+			newStore.Spec.Foo = "bar"
+			newStore.Generation = 2
+
+			Expect(objectStorePredicate.Update(event.UpdateEvent{ObjectOld: oldStore, ObjectNew: newStore})).To(BeTrue())
+		})
+
+		It("Should reconcile annotation updates", func() {
+			newStore := oldStore.DeepCopy()
+			newStore.Annotations = map[string]string{debugLogsAnnotation: "true"}
+
+			Expect(objectStorePredicate.Update(event.UpdateEvent{ObjectOld: oldStore, ObjectNew: newStore})).To(BeTrue())
+		})
+	})
+
+	Context("When periodically reconciling", func() {
+		var objectStore *objectv1alpha1.ObjectStore
+		var req ctrl.Request
+
+		BeforeEach(func() {
+			objectStore = &objectv1alpha1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-store"},
+			}
+			req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-store"}}
+		})
+
+		It("Should requeue a successful reconcile after the configured interval", func() {
+			r := &ObjectStoreReconciler{
+				Client:            newFakeClient(objectStore),
+				ReconcileInterval: 5 * time.Minute,
+			}
+
+			result, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{RequeueAfter: 5 * time.Minute}))
+		})
+
+		It("Should count a reconcile requeued after the interval as a success", func() {
+			r := &ObjectStoreReconciler{
+				Client:            newFakeClient(objectStore),
+				ReconcileInterval: 5 * time.Minute,
+			}
+			successesBefore := testutil.ToFloat64(reconcileTotal.WithLabelValues("success"))
+
+			_, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(reconcileTotal.WithLabelValues("success"))).To(Equal(successesBefore + 1))
+		})
+
+		It("Should not requeue when no interval is configured", func() {
+			r := &ObjectStoreReconciler{Client: newFakeClient(objectStore)}
+
+			result, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{}))
+		})
+
+		It("Should not requeue an object store that no longer exists", func() {
+			r := &ObjectStoreReconciler{
+				Client:            newFakeClient(),
+				ReconcileInterval: 5 * time.Minute,
+			}
+
+			result, err := r.Reconcile(context.Background(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{}))
+		})
+	})
+})
@@ -18,7 +18,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -52,11 +54,20 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var maxConcurrentReconciles int
+	var apiTimeout time.Duration
+	var reconcileInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The maximum number of ObjectStores that can be reconciled concurrently.")
+	flag.DurationVar(&apiTimeout, "api-timeout", 30*time.Second,
+		"The timeout applied to each API server call made while reconciling an ObjectStore.")
+	flag.DurationVar(&reconcileInterval, "reconcile-interval", 0,
+		"How often ObjectStores are re-reconciled to correct drift. Zero disables periodic reconciliation.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,6 +76,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if apiTimeout < 0 {
+		setupLog.Error(fmt.Errorf("invalid value %s", apiTimeout), "--api-timeout must not be negative")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -79,8 +95,11 @@ func main() {
 	}
 
 	if err = (&controllers.ObjectStoreReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		APITimeout:              apiTimeout,
+		ReconcileInterval:       reconcileInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ObjectStore")
 		os.Exit(1)